@@ -2,20 +2,39 @@ package mysql
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
-	"database/sql/driver"
 	"fmt"
+	"sync"
 
+	stdmysql "github.com/go-sql-driver/mysql"
 	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/webcore-go/lib-mysql/migrations"
 	libsql "github.com/webcore-go/lib-sql"
 	"github.com/webcore-go/webcore/app/config"
 	"github.com/webcore-go/webcore/app/loader"
+)
+
+// MysqlLoader's default driver name, used when DriverName is unset.
+const defaultDriverName = "mysql"
 
-	_ "github.com/go-sql-driver/mysql"
+var (
+	registeredDriversMu sync.Mutex
+	registeredDrivers   = map[string]bool{}
 )
 
 type MysqlLoader struct {
 	name string
+
+	// DriverName is the name this loader registers and opens its
+	// connections under. Defaults to "mysql". Set it to a unique value
+	// per loader instance when an app needs more than one MySQL
+	// connection with its own driver registration (e.g. a distinct
+	// interceptor wrapping go-sql-driver/mysql), so they don't collide on
+	// the shared "mysql" name. Note that RegisterTLSConfig and
+	// RegisterDialContext are NOT scoped by DriverName — see their doc
+	// comments.
+	DriverName string
 }
 
 func (a *MysqlLoader) SetName(name string) {
@@ -26,143 +45,168 @@ func (a *MysqlLoader) Name() string {
 	return a.name
 }
 
-func (l *MysqlLoader) Init(args ...any) (loader.Library, error) {
-	config := args[1].(config.DatabaseConfig)
-	dsn := libsql.BuildDSN(config)
-
-	db := &libsql.SQLDatabase{}
-
-	driver := libsql.NewConnector("mysql", &Connector{dsn: dsn})
-	dialect := mysqldialect.New()
-
-	// Set up Bun SQL database wrapper
-	db.SetBunDB(driver, dialect)
-
-	err := db.Install(args...)
-	if err != nil {
-		return nil, err
+func (l *MysqlLoader) driverName() string {
+	if l.DriverName != "" {
+		return l.DriverName
 	}
-
-	db.Connect()
-
-	// l.DB = db
-	return db, nil
-}
-
-// ----------------------- Connector -------------------
-
-// Connector wraps the MySQL standard driver
-type Connector struct {
-	dsn string
-}
-
-var _ driver.Connector = (*Connector)(nil)
-
-func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
-	db, err := sql.Open("mysql", c.dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open mysql: %w", err)
+	return defaultDriverName
+}
+
+// RegisterTLSConfig registers cfg under key so it can be referenced from
+// this loader's DSN with a "tls=key" parameter. Must be called before
+// Init.
+//
+// The registry is process-global in go-sql-driver/mysql, not scoped to
+// this loader's DriverName: registering the same key from two loaders
+// overwrites the first registration. Give each loader that needs its own
+// TLS config a distinct key.
+func (l *MysqlLoader) RegisterTLSConfig(key string, cfg *tls.Config) error {
+	return stdmysql.RegisterTLSConfig(key, cfg)
+}
+
+// RegisterDialContext registers a custom dial function for connections
+// opened under net (e.g. "tcp"). Must be called before Init.
+//
+// The registry is process-global in go-sql-driver/mysql, not scoped to
+// this loader's DriverName: registering a second dial function for the
+// same net name replaces the first for every loader. Give each loader
+// that needs its own dial behavior a distinct net name.
+func (l *MysqlLoader) RegisterDialContext(net string, dial stdmysql.DialContextFunc) {
+	stdmysql.RegisterDialContext(net, dial)
+}
+
+// registerDriver registers name against the go-sql-driver/mysql driver
+// exactly once, so multiple MysqlLoader instances using distinct
+// DriverNames can each open connections without colliding on the "mysql"
+// name the driver already registers itself under on import. A name the
+// app has already registered with database/sql (e.g. an interceptor
+// wrapping go-sql-driver/mysql) is left as it is and used as-is.
+func registerDriver(name string) {
+	registeredDriversMu.Lock()
+	defer registeredDriversMu.Unlock()
+
+	if registeredDrivers[name] {
+		return
 	}
-
-	// Verify connection
-	if err := db.PingContext(ctx); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to ping mysql: %w", err)
+	for _, existing := range sql.Drivers() {
+		if existing == name {
+			registeredDrivers[name] = true
+			return
+		}
 	}
-
-	return &mysqlConn{db: db}, nil
-}
-
-func (c *Connector) Driver() driver.Driver {
-	return libsql.NewDriver()
+	sql.Register(name, &stdmysql.MySQLDriver{})
+	registeredDrivers[name] = true
 }
 
-// mysqlConn wraps the MySQL database connection
-type mysqlConn struct {
-	db *sql.DB
-}
+func (l *MysqlLoader) Init(args ...any) (loader.Library, error) {
+	dbConfig := args[1].(config.DatabaseConfig)
+	dsn := libsql.BuildDSN(dbConfig)
 
-func (c *mysqlConn) Prepare(query string) (driver.Stmt, error) {
-	stmt, err := c.db.PrepareContext(context.Background(), query)
-	if err != nil {
-		return nil, err
+	driverName := l.driverName()
+	if driverName != defaultDriverName {
+		registerDriver(driverName)
 	}
-	return &mysqlStmt{stmt: stmt}, nil
-}
-
-func (c *mysqlConn) Close() error {
-	return c.db.Close()
-}
 
-func (c *mysqlConn) Begin() (driver.Tx, error) {
-	tx, err := c.db.BeginTx(context.Background(), nil)
-	if err != nil {
-		return nil, err
+	bunDB := libsql.NewConnector(driverName, &Connector{
+		dsn:        dsn,
+		DriverName: driverName,
+		retry: RetryPolicy{
+			MaxAttempts: dbConfig.ConnectRetryMaxAttempts,
+			Backoff:     dbConfig.ConnectRetryBackoff,
+			MaxBackoff:  dbConfig.ConnectRetryMaxBackoff,
+			Jitter:      dbConfig.ConnectRetryJitter,
+		},
+	})
+
+	// Migrations need a *sql.DB whose Conn() pins one real backend
+	// session for the lifetime of the advisory lock. bunDB's underlying
+	// mysqlConn delegates Prepare to its own inner *sql.DB pool, so a
+	// pinned outer connection can still have GET_LOCK and RELEASE_LOCK
+	// land on two different sessions. Open a direct pool from the same
+	// DSN instead of reusing bunDB for this.
+	var migrateDB *sql.DB
+	if dbConfig.AutoMigrate {
+		var err error
+		migrateDB, err = sql.Open(driverName, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("mysql: open migration connection: %w", err)
+		}
+		defer migrateDB.Close()
 	}
-	return &mysqlTx{tx: tx}, nil
-}
-
-// mysqlStmt wraps the MySQL statement
-type mysqlStmt struct {
-	stmt *sql.Stmt
-}
 
-func (s *mysqlStmt) Close() error {
-	return s.stmt.Close()
-}
-
-func (s *mysqlStmt) NumInput() int {
-	return -1
-}
+	return l.init(bunDB, migrateDB, dbConfig, args...)
+}
+
+// InitWithDB wires an already-opened *sql.DB instead of building a DSN
+// and opening a new pool. Use it to share a *sql.DB across subsystems,
+// inject a mock in tests, or reuse a pool configured with custom
+// DialContext, TLS, or observability wrappers (OpenTelemetry,
+// sqlcommenter). The Bun dialect setup and health-check logic are the
+// same as Init.
+//
+// There is deliberately no InitWithConn accepting a single *sql.Conn:
+// the only way to make Bun treat one already-checked-out connection as
+// its own *sql.DB is to unwrap it via conn.Raw and smuggle the raw
+// driver.Conn into a second driver.Connector, which both violates the
+// "must not be used outside of f" contract of conn.Raw and leaves the
+// loader permanently broken (with no DSN to reconnect from) the moment
+// that connection drops. Callers that only have a *sql.Conn should open
+// their own *sql.DB instead and pass it here.
+func (l *MysqlLoader) InitWithDB(db *sql.DB, dbConfig config.DatabaseConfig, args ...any) (loader.Library, error) {
+	return l.init(db, db, dbConfig, installArgs(dbConfig, args)...)
+}
+
+// installArgs reconstructs the (_, config.DatabaseConfig, ...) argument
+// shape that db.Install expects, for the entry points that don't already
+// receive it from a loader-framework caller.
+func installArgs(dbConfig config.DatabaseConfig, extra []any) []any {
+	return append([]any{nil, dbConfig}, extra...)
+}
+
+// init wires dialect setup, Install, Connect (health-check), and the
+// optional auto-migrate step that both entry points share once they have
+// a Bun-ready *sql.DB. migrateDB is the *sql.DB migrations run against;
+// it must be a real, directly-opened pool rather than one routed through
+// Connector, so that the advisory lock's GET_LOCK/RELEASE_LOCK pair
+// lands on the same backend session (see Init). It may be nil when
+// dbConfig.AutoMigrate is false.
+func (l *MysqlLoader) init(bunDB *sql.DB, migrateDB *sql.DB, dbConfig config.DatabaseConfig, args ...any) (loader.Library, error) {
+	db := &libsql.SQLDatabase{}
+	dialect := mysqldialect.New()
 
-func (s *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
-	result, err := s.stmt.ExecContext(context.Background(), libsql.ToNamedValues(args)...)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
-}
+	// Set up Bun SQL database wrapper
+	db.SetBunDB(bunDB, dialect)
 
-func (s *mysqlStmt) Query(args []driver.Value) (driver.Rows, error) {
-	rows, err := s.stmt.QueryContext(context.Background(), libsql.ToNamedValues(args)...)
-	if err != nil {
+	if err := db.Install(args...); err != nil {
 		return nil, err
 	}
-	return &mysqlRows{rows: rows}, nil
-}
-
-// mysqlTx wraps the MySQL transaction
-type mysqlTx struct {
-	tx *sql.Tx
-}
-
-func (t *mysqlTx) Commit() error {
-	return t.tx.Commit()
-}
-
-func (t *mysqlTx) Rollback() error {
-	return t.tx.Rollback()
-}
 
-// mysqlRows wraps the MySQL rows
-type mysqlRows struct {
-	rows *sql.Rows
-}
+	db.Connect()
 
-func (r *mysqlRows) Columns() []string {
-	cols, _ := r.rows.Columns()
-	return cols
-}
+	if dbConfig.AutoMigrate {
+		if err := l.migrate(context.Background(), migrateDB, dbConfig); err != nil {
+			return nil, fmt.Errorf("mysql: auto-migrate: %w", err)
+		}
+	}
 
-func (r *mysqlRows) Close() error {
-	return r.rows.Close()
+	// l.DB = db
+	return db, nil
 }
 
-func (r *mysqlRows) Next(dest []driver.Value) error {
-	// Convert []driver.Value to []any
-	args := make([]any, len(dest))
-	for i := range dest {
-		args[i] = &dest[i]
+// migrate runs pending migrations discovered from dbConfig's migrations
+// directory (or embedded FS). It backs the auto-migrate step that init
+// runs for both Init and InitWithDB; it is unexported, so code outside
+// this package cannot call it.
+func (l *MysqlLoader) migrate(ctx context.Context, sqlDB *sql.DB, dbConfig config.DatabaseConfig) error {
+	migrator := migrations.New(sqlDB, migrations.Config{
+		Dir:              dbConfig.MigrationsDir,
+		FS:               dbConfig.MigrationsFS,
+		NoLock:           dbConfig.MigrationsNoLock,
+		StatementTimeout: dbConfig.MigrationsStatementTimeout,
+	})
+
+	if err := migrator.Up(ctx); err != nil && err != migrations.ErrNoChange {
+		return err
 	}
-	return r.rows.Scan(args...)
+	return nil
 }
@@ -0,0 +1,376 @@
+// Package migrations implements a small golang-migrate-style schema
+// migration runner for MysqlLoader. Versions are tracked in a
+// schema_migrations table and applied from a directory (or embed.FS) of
+// paired up/down SQL files, with an advisory lock so that multiple
+// instances of the same app don't apply migrations concurrently.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrNoChange is returned by Up, Down and Steps when there are no
+// migrations left to apply in the requested direction.
+var ErrNoChange = errors.New("migrations: no change")
+
+// ErrDirty is returned when the schema_migrations table reports a dirty
+// version, meaning a previous migration failed partway through and needs
+// to be resolved with Force before any further migrations can run.
+type ErrDirty struct {
+	Version int
+}
+
+func (e *ErrDirty) Error() string {
+	return fmt.Sprintf("migrations: database is dirty at version %d, resolve with Force", e.Version)
+}
+
+// Config controls how a Migrator discovers and applies migrations.
+//
+// Each migration file is sent to the server as a single query. If any
+// file contains more than one SQL statement, the DSN Migrator's *sql.DB
+// was opened with must include "multiStatements=true" (a
+// go-sql-driver/mysql parameter); without it, go-sql-driver/mysql
+// rejects multi-statement strings and the migration fails at runtime.
+type Config struct {
+	// Dir is a directory on disk to read migration files from. Ignored
+	// if FS is set.
+	Dir string
+
+	// FS is an embed.FS (or any fs.FS) to read migration files from,
+	// taking precedence over Dir.
+	FS fs.FS
+
+	// NoLock disables the GET_LOCK/RELEASE_LOCK advisory lock, for
+	// managed databases (e.g. some read replicas or proxies) where
+	// GET_LOCK is unavailable.
+	NoLock bool
+
+	// LockName overrides the advisory lock name. Defaults to
+	// "lib-mysql:migrations".
+	LockName string
+
+	// StatementTimeout bounds each individual migration statement. Zero
+	// means no timeout beyond the caller's context.
+	StatementTimeout time.Duration
+}
+
+// Migrator applies versioned SQL migrations against a *sql.DB.
+type Migrator struct {
+	db  *sql.DB
+	cfg Config
+}
+
+// New returns a Migrator that reads migrations according to cfg and
+// applies them against db.
+func New(db *sql.DB, cfg Config) *Migrator {
+	if cfg.LockName == "" {
+		cfg.LockName = "lib-mysql:migrations"
+	}
+	return &Migrator{db: db, cfg: cfg}
+}
+
+// migration describes one discovered version.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+var fileRE = regexp.MustCompile(`^(\d+)_([^.]+)\.(up|down)\.sql$`)
+
+// osDirFS adapts a plain directory path to an fs.FS for migration file
+// discovery, mirroring the FS option's read path.
+func osDirFS(dir string) fs.FS {
+	return os.DirFS(dir)
+}
+
+// load discovers and parses migration files, returning them sorted by
+// version ascending.
+func (m *Migrator) load() ([]migration, error) {
+	var entries []fs.DirEntry
+	var read func(name string) ([]byte, error)
+
+	if m.cfg.FS != nil {
+		dirEntries, err := fs.ReadDir(m.cfg.FS, ".")
+		if err != nil {
+			return nil, fmt.Errorf("migrations: read fs: %w", err)
+		}
+		entries = dirEntries
+		read = func(name string) ([]byte, error) { return fs.ReadFile(m.cfg.FS, name) }
+	} else {
+		dirEntries, err := fs.ReadDir(osDirFS(m.cfg.Dir), ".")
+		if err != nil {
+			return nil, fmt.Errorf("migrations: read dir %s: %w", m.cfg.Dir, err)
+		}
+		entries = dirEntries
+		read = func(name string) ([]byte, error) { return fs.ReadFile(osDirFS(m.cfg.Dir), name) }
+	}
+
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileRE.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("migrations: invalid version in %s: %w", entry.Name(), err)
+		}
+		contents, err := read(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrations: read %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{version: version, name: match[2]}
+			byVersion[version] = mig
+		}
+		switch match[3] {
+		case "up":
+			mig.up = string(contents)
+		case "down":
+			mig.down = string(contents)
+		}
+	}
+
+	out := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		out = append(out, *mig)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	return out, nil
+}
+
+// Version returns the currently applied migration version and whether it
+// is in a dirty state. It returns version 0 and ok=false if no migration
+// has ever been applied.
+func (m *Migrator) Version(ctx context.Context) (version int, dirty bool, ok bool, err error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return 0, false, false, err
+	}
+	row := m.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+	if err := row.Scan(&version, &dirty); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, false, nil
+		}
+		return 0, false, false, err
+	}
+	return version, dirty, true, nil
+}
+
+// Up applies all migrations newer than the current version.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, 0, true)
+}
+
+// Down reverts all applied migrations.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.run(ctx, 0, false)
+}
+
+// Steps applies n migrations forward (n > 0) or |n| migrations backward
+// (n < 0) from the current version.
+func (m *Migrator) Steps(ctx context.Context, n int) error {
+	if n == 0 {
+		return nil
+	}
+	if n > 0 {
+		return m.run(ctx, n, true)
+	}
+	return m.run(ctx, -n, false)
+}
+
+// Force sets the recorded version without running any migration,
+// clearing the dirty flag. Use it to recover from a migration that
+// failed partway through.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return m.setVersion(ctx, version, false)
+}
+
+// run applies up to `limit` migrations (0 means no limit) in the given
+// direction, under the advisory lock.
+func (m *Migrator) run(ctx context.Context, limit int, up bool) error {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+
+	current, dirty, _, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return &ErrDirty{Version: current}
+	}
+
+	all, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	pending := pendingMigrations(all, current, up)
+	if limit > 0 && limit < len(pending) {
+		pending = pending[:limit]
+	}
+	if len(pending) == 0 {
+		return ErrNoChange
+	}
+
+	for _, mig := range pending {
+		if err := m.apply(ctx, mig, up, all); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingMigrations returns the migrations that still need to run to
+// move away from current in the requested direction, in application
+// order.
+func pendingMigrations(all []migration, current int, up bool) []migration {
+	var pending []migration
+	if up {
+		for _, mig := range all {
+			if mig.version > current {
+				pending = append(pending, mig)
+			}
+		}
+		return pending
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].version <= current {
+			pending = append(pending, all[i])
+		}
+	}
+	return pending
+}
+
+// previousVersion returns the version of the migration immediately
+// before v in all (all is sorted ascending), or 0 if none precedes it.
+// Versions aren't assumed to be contiguous, so reverting v must land on
+// whatever version actually came before it, not v-1.
+func previousVersion(all []migration, v int) int {
+	prev := 0
+	for _, mig := range all {
+		if mig.version >= v {
+			break
+		}
+		prev = mig.version
+	}
+	return prev
+}
+
+// apply runs a single migration's SQL and updates schema_migrations,
+// marking the version dirty for the duration so a crash mid-migration is
+// detectable on the next run.
+func (m *Migrator) apply(ctx context.Context, mig migration, up bool, all []migration) error {
+	target := mig.version
+	script := mig.up
+	if !up {
+		target = previousVersion(all, mig.version)
+		script = mig.down
+	}
+
+	if err := m.setVersion(ctx, mig.version, true); err != nil {
+		return err
+	}
+
+	execCtx := ctx
+	if m.cfg.StatementTimeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, m.cfg.StatementTimeout)
+		defer cancel()
+	}
+
+	// script is sent as a single query; multi-statement files require
+	// multiStatements=true on the DSN (see Config's doc comment).
+	if _, err := m.db.ExecContext(execCtx, script); err != nil {
+		return fmt.Errorf("migrations: apply %d_%s: %w", mig.version, mig.name, err)
+	}
+
+	return m.setVersion(ctx, target, false)
+}
+
+// ensureVersionTable creates the schema_migrations table if it doesn't
+// already exist.
+func (m *Migrator) ensureVersionTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty    BOOLEAN NOT NULL
+		)
+	`)
+	return err
+}
+
+func (m *Migrator) setVersion(ctx context.Context, version int, dirty bool) error {
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	_, err := m.db.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)", version, dirty)
+	return err
+}
+
+// lock acquires the advisory lock on a dedicated connection for the
+// duration of a migration run, so that concurrent app instances don't
+// apply migrations at the same time. The returned func releases the lock
+// and returns the connection to the pool.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	if m.cfg.NoLock {
+		return func() {}, nil
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: acquire lock conn: %w", err)
+	}
+
+	var acquired int
+	row := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 10)", m.cfg.LockName)
+	if err := row.Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("migrations: GET_LOCK: %w", err)
+	}
+	if acquired != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("migrations: could not acquire lock %q", m.cfg.LockName)
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.cfg.LockName)
+		conn.Close()
+	}, nil
+}
+
+// EmbedFS is a convenience alias so callers can declare their migrations
+// directory with //go:embed without importing embed directly.
+type EmbedFS = embed.FS
@@ -0,0 +1,296 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+	"time"
+
+	libsql "github.com/webcore-go/lib-sql"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// RetryPolicy controls how Connector retries the initial sql.Open/Ping
+// handshake when a connection is first established. The zero value
+// means "try once, no backoff".
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Zero or one means no retries.
+	MaxAttempts int
+
+	// Backoff is the delay before the second attempt; it doubles after
+	// each further failure up to MaxBackoff.
+	Backoff time.Duration
+
+	// MaxBackoff caps the backoff between attempts; Jitter is added after
+	// the cap, so the actual delay can exceed it. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Jitter adds up to Jitter*delay of random delay on top of the
+	// backoff, to avoid a thundering herd of reconnecting instances.
+	Jitter float64
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts <= 0 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+	d := p.Backoff * time.Duration(int64(1)<<uint(attempt-1))
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// Connector wraps the MySQL standard driver
+type Connector struct {
+	dsn   string
+	retry RetryPolicy
+
+	// DriverName is the name this connector's connections are opened
+	// under via sql.Open. It must already be registered with sql.Register
+	// (go-sql-driver/mysql registers "mysql" on import; MysqlLoader
+	// registers any custom DriverName that isn't registered yet).
+	// Defaults to "mysql".
+	DriverName string
+}
+
+func (c *Connector) driverName() string {
+	if c.DriverName != "" {
+		return c.DriverName
+	}
+	return "mysql"
+}
+
+var _ driver.Connector = (*Connector)(nil)
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	attempts := c.retry.attempts()
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		conn, err := c.connectOnce(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+
+		if attempt == attempts {
+			break
+		}
+		if delay := c.retry.delay(attempt); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *Connector) connectOnce(ctx context.Context) (driver.Conn, error) {
+	db, err := sql.Open(c.driverName(), c.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mysql: %w", err)
+	}
+
+	// Verify connection
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping mysql: %w", err)
+	}
+
+	return &mysqlConn{db: db}, nil
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return libsql.NewDriver()
+}
+
+// mysqlConn wraps the MySQL database connection
+type mysqlConn struct {
+	db *sql.DB
+}
+
+var (
+	_ driver.Conn               = (*mysqlConn)(nil)
+	_ driver.ConnPrepareContext = (*mysqlConn)(nil)
+	_ driver.ConnBeginTx        = (*mysqlConn)(nil)
+	_ driver.Pinger             = (*mysqlConn)(nil)
+)
+
+func (c *mysqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *mysqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlStmt{stmt: stmt}, nil
+}
+
+func (c *mysqlConn) Close() error {
+	return c.db.Close()
+}
+
+func (c *mysqlConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *mysqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	txOpts, err := txOptionsFromDriver(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.db.BeginTx(ctx, txOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlTx{tx: tx}, nil
+}
+
+func (c *mysqlConn) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+// txOptionsFromDriver translates the isolation level and read-only flag
+// the database/sql package hands the driver into the equivalent sql.TxOptions.
+func txOptionsFromDriver(opts driver.TxOptions) (*sql.TxOptions, error) {
+	level := sql.IsolationLevel(opts.Isolation)
+	if level != sql.LevelDefault {
+		if _, err := isolationLevelName(level); err != nil {
+			return nil, err
+		}
+	}
+	return &sql.TxOptions{
+		Isolation: level,
+		ReadOnly:  opts.ReadOnly,
+	}, nil
+}
+
+func isolationLevelName(level sql.IsolationLevel) (string, error) {
+	switch level {
+	case sql.LevelDefault:
+		return "", nil
+	case sql.LevelReadUncommitted:
+		return "READ UNCOMMITTED", nil
+	case sql.LevelReadCommitted:
+		return "READ COMMITTED", nil
+	case sql.LevelRepeatableRead:
+		return "REPEATABLE READ", nil
+	case sql.LevelSerializable:
+		return "SERIALIZABLE", nil
+	default:
+		return "", fmt.Errorf("mysql: isolation level %v is not supported", level)
+	}
+}
+
+// mysqlStmt wraps the MySQL statement
+type mysqlStmt struct {
+	stmt *sql.Stmt
+}
+
+var (
+	_ driver.Stmt             = (*mysqlStmt)(nil)
+	_ driver.StmtExecContext  = (*mysqlStmt)(nil)
+	_ driver.StmtQueryContext = (*mysqlStmt)(nil)
+)
+
+func (s *mysqlStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *mysqlStmt) NumInput() int {
+	return -1
+}
+
+func (s *mysqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), libsql.ToNamedValues(args))
+}
+
+func (s *mysqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	result, err := s.stmt.ExecContext(ctx, namedValuesToArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mysqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), libsql.ToNamedValues(args))
+}
+
+func (s *mysqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.stmt.QueryContext(ctx, namedValuesToArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlRows{rows: rows}, nil
+}
+
+// namedValuesToArgs converts the driver-level named values back into plain
+// arguments accepted by database/sql's *Context methods.
+func namedValuesToArgs(args []driver.NamedValue) []any {
+	out := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			out[i] = sql.Named(arg.Name, arg.Value)
+			continue
+		}
+		out[i] = arg.Value
+	}
+	return out
+}
+
+// mysqlTx wraps the MySQL transaction
+type mysqlTx struct {
+	tx *sql.Tx
+}
+
+func (t *mysqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *mysqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// mysqlRows wraps the MySQL rows
+type mysqlRows struct {
+	rows *sql.Rows
+}
+
+func (r *mysqlRows) Columns() []string {
+	cols, _ := r.rows.Columns()
+	return cols
+}
+
+func (r *mysqlRows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *mysqlRows) Next(dest []driver.Value) error {
+	// Convert []driver.Value to []any
+	args := make([]any, len(dest))
+	for i := range dest {
+		args[i] = &dest[i]
+	}
+	return r.rows.Scan(args...)
+}